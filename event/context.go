@@ -0,0 +1,209 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !disable_events
+
+package event
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Exporter delivers Events to a Handler. Now defaults to time.Now and is
+// only ever overridden in tests.
+type Exporter struct {
+	handler Handler
+
+	mu     sync.Mutex
+	lastID uint64
+
+	Now func() time.Time
+}
+
+// NewExporter creates an Exporter that delivers events to h.
+func NewExporter(h Handler) *Exporter {
+	return &Exporter{handler: h, Now: time.Now}
+}
+
+func (e *Exporter) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+	return time.Now()
+}
+
+func (e *Exporter) nextID() uint64 {
+	e.mu.Lock()
+	e.lastID++
+	id := e.lastID
+	e.mu.Unlock()
+	return id
+}
+
+type contextKeyType struct{}
+
+var contextKey contextKeyType
+
+type exporterState struct {
+	exporter *Exporter
+	parent   uint64
+}
+
+func newContext(ctx context.Context, e *Exporter, parent uint64) context.Context {
+	return context.WithValue(ctx, contextKey, exporterState{exporter: e, parent: parent})
+}
+
+var (
+	defaultMu       sync.Mutex
+	defaultExporter *Exporter
+)
+
+func setDefaultExporter(e *Exporter) {
+	defaultMu.Lock()
+	defaultExporter = e
+	defaultMu.Unlock()
+}
+
+func exporterFromContext(ctx context.Context) (*Exporter, uint64) {
+	if s, ok := ctx.Value(contextKey).(exporterState); ok {
+		return s.exporter, s.parent
+	}
+	defaultMu.Lock()
+	e := defaultExporter
+	defaultMu.Unlock()
+	return e, 0
+}
+
+// FromContext reports the Exporter that To and Span would deliver to for
+// ctx: the one attached by WithExporter, or the process-wide default set by
+// SetDefaultExporter, or (ok == false) neither.
+func FromContext(ctx context.Context) (e *Exporter, ok bool) {
+	e, _ = exporterFromContext(ctx)
+	return e, e != nil
+}
+
+// Builder builds up an Event's labels and message, then delivers it to
+// whichever Exporter is attached to its context, if any.
+type Builder struct {
+	ctx      context.Context
+	exporter *Exporter
+	parent   uint64
+	labels   []Label
+}
+
+// To starts building an Event that will be delivered through the Exporter
+// attached to ctx (see WithExporter and SetDefaultExporter). It is a no-op,
+// not an error, to call To on a context with no Exporter attached.
+func To(ctx context.Context) Builder {
+	e, parent := exporterFromContext(ctx)
+	return Builder{ctx: ctx, exporter: e, parent: parent}
+}
+
+// Clone returns a copy of b, so that a common prefix of labels can be
+// built once and reused across several events.
+func (b Builder) Clone() Builder { return b }
+
+// With returns a Builder with label appended to its set of labels.
+func (b Builder) With(label Label) Builder {
+	b.labels = append(append([]Label(nil), b.labels...), label)
+	return b
+}
+
+// WithAll returns a Builder with labels appended to its set of labels.
+func (b Builder) WithAll(labels ...Label) Builder {
+	b.labels = append(append([]Label(nil), b.labels...), labels...)
+	return b
+}
+
+// Event returns the Event that b has built so far, stamped with an id and
+// timestamp from the Builder's Exporter.
+func (b Builder) Event() *Event {
+	ev := &Event{Parent: b.parent, Labels: b.labels}
+	if b.exporter != nil {
+		ev.ID = b.exporter.nextID()
+		ev.At = b.exporter.now()
+	}
+	return ev
+}
+
+// Log delivers a logging Event with the given message.
+func (b Builder) Log(message string) {
+	if b.exporter == nil {
+		return
+	}
+	ev := b.Event()
+	ev.Message = message
+	b.exporter.handler.Log(b.ctx, ev)
+}
+
+// Logf is Log, with the message built by fmt.Sprintf(template, args...).
+func (b Builder) Logf(template string, args ...interface{}) {
+	b.Log(fmt.Sprintf(template, args...))
+}
+
+// Metric delivers a metric-record Event.
+func (b Builder) Metric() {
+	if b.exporter == nil {
+		return
+	}
+	b.exporter.handler.Metric(b.ctx, b.Event())
+}
+
+// Annotate delivers an Event reporting label values at a point in time.
+func (b Builder) Annotate() {
+	if b.exporter == nil {
+		return
+	}
+	b.exporter.handler.Annotate(b.ctx, b.Event())
+}
+
+// End is provided for parity with the disabled build; Builder-built events
+// have no corresponding End, only Span-built ones do.
+func (b Builder) End() {}
+
+// SpanBuilder builds up a span-start Event, then starts the span.
+type SpanBuilder struct {
+	ctx      context.Context
+	exporter *Exporter
+	parent   uint64
+	labels   []Label
+}
+
+// Span starts building a span Event that will be delivered through the
+// Exporter attached to ctx.
+func Span(ctx context.Context) SpanBuilder {
+	e, parent := exporterFromContext(ctx)
+	return SpanBuilder{ctx: ctx, exporter: e, parent: parent}
+}
+
+// With returns a SpanBuilder with label appended to its set of labels.
+func (b SpanBuilder) With(label Label) SpanBuilder {
+	b.labels = append(append([]Label(nil), b.labels...), label)
+	return b
+}
+
+// WithAll returns a SpanBuilder with labels appended to its set of labels.
+func (b SpanBuilder) WithAll(labels ...Label) SpanBuilder {
+	b.labels = append(append([]Label(nil), b.labels...), labels...)
+	return b
+}
+
+// Start starts the span named name, returning a context that nested
+// To/Span calls should use so that their events are attributed as children
+// of this span, and a function that must be called to end the span.
+func (b SpanBuilder) Start(name string) (context.Context, func()) {
+	if b.exporter == nil {
+		return b.ctx, func() {}
+	}
+	id := b.exporter.nextID()
+	ev := &Event{ID: id, Parent: b.parent, At: b.exporter.now(), Message: name, Labels: b.labels}
+	ctx := b.exporter.handler.Start(b.ctx, ev)
+	ctx = newContext(ctx, b.exporter, id)
+	return ctx, func() {
+		b.exporter.handler.End(ctx, &Event{ID: b.exporter.nextID(), Parent: id, At: b.exporter.now()})
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+// Label is a named value attached to an Event, used to carry structured
+// diagnostic data (as opposed to the free-form Message).
+type Label struct {
+	Name  string
+	Value interface{}
+}
+
+// String returns a Label with a string value.
+func String(name, value string) Label { return Label{Name: name, Value: value} }
+
+// Int64 returns a Label with an int64 value.
+func Int64(name string, value int64) Label { return Label{Name: name, Value: value} }
+
+// Value returns a Label with an arbitrary value.
+func Value(name string, value interface{}) Label { return Label{Name: name, Value: value} }
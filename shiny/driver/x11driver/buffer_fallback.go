@@ -0,0 +1,124 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11driver
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// bufferUploader is implemented by fallbackBufferImpl, which uploads itself
+// to a window or texture. The SHM-backed bufferImpl does not implement it:
+// screenImpl.upload handles that case itself, so that it can wait for the
+// asynchronous SHM completion event.
+type bufferUploader interface {
+	screen.Buffer
+	upload(xd xproto.Drawable, xg xproto.Gcontext, depth uint8, dp image.Point, sr image.Rectangle, sender screen.Sender, uploadedEvent screen.UploadedEvent)
+}
+
+// putImageChunkSize is the maximum number of bytes of pixel data sent in a
+// single xproto.PutImage request. xgb, like most X11 clients, has to keep
+// an entire request in memory before writing it to the wire, and without
+// the BIG-REQUESTS extension the X server caps a request, header included,
+// at its maximum-request-length of 2^16 - 1 four-byte units, just under
+// 256 KiB. Chunking keeps fallback uploads well under that limit regardless
+// of buffer size.
+const putImageChunkSize = 1 << 17 // 128 KiB.
+
+// fallbackBufferImpl is the screen.Buffer used when s.shmAvailable is false,
+// e.g. because the X11 connection is remote or forwarded, or the server
+// lacks the MIT-SHM extension. Its pixels live in ordinary Go memory and are
+// pushed to the server with plain xproto.PutImage requests instead of a
+// shared memory pixmap.
+type fallbackBufferImpl struct {
+	s    *screenImpl
+	rgba image.RGBA
+	size image.Point
+}
+
+func newFallbackBuffer(s *screenImpl, size image.Point) (retBuf screen.Buffer, retErr error) {
+	w, h := int64(size.X), int64(size.Y)
+	if w <= 0 || maxShmSide < w || h <= 0 || maxShmSide < h || maxShmSize < 4*w*h {
+		return nil, fmt.Errorf("x11driver: invalid buffer size %v", size)
+	}
+
+	b := &fallbackBufferImpl{
+		s: s,
+		rgba: image.RGBA{
+			Pix:    make([]byte, 4*size.X*size.Y),
+			Stride: 4 * size.X,
+			Rect:   image.Rectangle{Max: size},
+		},
+		size: size,
+	}
+	return b, nil
+}
+
+func (b *fallbackBufferImpl) Size() image.Point       { return b.size }
+func (b *fallbackBufferImpl) Bounds() image.Rectangle { return image.Rectangle{Max: b.size} }
+func (b *fallbackBufferImpl) RGBA() *image.RGBA       { return &b.rgba }
+func (b *fallbackBufferImpl) Release()                {}
+
+// upload pushes the sub-image of the buffer's pixels defined by sr to the
+// drawable xd at dp, via a sequence of xproto.PutImage requests, each
+// covering a horizontal band of at most putImageChunkSize bytes. Unlike the
+// SHM path, there is no asynchronous completion event to wait for: the data
+// is copied into the request itself, so the upload is complete (from the
+// client's point of view) as soon as PutImage returns. handleCompletion is
+// therefore never consulted in fallback mode; instead, an UploadedEvent is
+// synthesized and sent immediately, mirroring what handleCompletion does
+// for the SHM path.
+func (b *fallbackBufferImpl) upload(xd xproto.Drawable, xg xproto.Gcontext, depth uint8, dp image.Point, sr image.Rectangle, sender screen.Sender, uploadedEvent screen.UploadedEvent) {
+	end := b.s.startUploadSpan(xd, sr.Size())
+	defer end()
+
+	width, height := sr.Dx(), sr.Dy()
+	if width > 0 && height > 0 {
+		rowBytes := 4 * width
+		rowsPerChunk := putImageChunkSize / rowBytes
+		if rowsPerChunk < 1 {
+			rowsPerChunk = 1
+		}
+
+		// sr's width may be narrower than the buffer's own stride, so each
+		// row of the request has to be copied out individually rather than
+		// sliced straight out of b.rgba.Pix.
+		data := make([]byte, rowsPerChunk*rowBytes)
+		for y := 0; y < height; y += rowsPerChunk {
+			h := rowsPerChunk
+			if y+h > height {
+				h = height - y
+			}
+			chunk := data[:h*rowBytes]
+			for row := 0; row < h; row++ {
+				srcY := sr.Min.Y + y + row
+				srcStart := srcY*b.rgba.Stride + 4*sr.Min.X
+				dst := chunk[row*rowBytes : (row+1)*rowBytes]
+				copy(dst, b.rgba.Pix[srcStart:srcStart+rowBytes])
+				// The server's pixel layout is BGRA (see findPictformat and
+				// findVisual), but image.RGBA's is RGBA: swap red and blue.
+				for i := 0; i < len(dst); i += 4 {
+					dst[i+0], dst[i+2] = dst[i+2], dst[i+0]
+				}
+			}
+			xproto.PutImage(
+				b.s.xc, xproto.ImageFormatZPixmap, xd, xg,
+				uint16(width), uint16(h),
+				int16(dp.X), int16(dp.Y+y), 0, depth, chunk,
+			)
+		}
+	}
+
+	if sender != nil {
+		// Call Send in a separate goroutine, matching handleCompletion's
+		// treatment of the SHM completion path, so that callers can't rely
+		// on (and don't block on) the event being delivered synchronously.
+		go sender.Send(uploadedEvent)
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11driver
+
+import (
+	"fmt"
+	"log"
+
+	"golang.org/x/exp/event"
+)
+
+// logf reports a diagnostic message, with format and args combined via
+// fmt.Sprintf. When an *event.Exporter is attached to s.ctx (see
+// newScreenImpl), or failing that one was set with event.SetDefaultExporter,
+// the message and labels are routed through event.To so
+// that programs instrumented with golang.org/x/exp/event can observe
+// x11driver's internals alongside their own events. Otherwise, it falls
+// back to the standard log package, exactly as x11driver always has.
+func (s *screenImpl) logf(format string, labels []event.Label, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if _, ok := event.FromContext(s.ctx); ok {
+		event.To(s.ctx).WithAll(labels...).Log(message)
+		return
+	}
+	log.Printf("%s", message)
+}
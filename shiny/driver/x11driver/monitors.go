@@ -0,0 +1,140 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11driver
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgb/xinerama"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// initMonitors prepares whichever multi-monitor extension the X server
+// supports. RandR is preferred, as it reports physical size, name and
+// refresh rate per output; Xinerama, used by older servers, only reports
+// bounds.
+func (s *screenImpl) initMonitors() error {
+	if err := randr.Init(s.xc); err == nil {
+		if _, err := randr.QueryVersion(s.xc, 1, 5).Reply(); err == nil {
+			s.randrAvailable = true
+			randr.SelectInput(s.xc, s.xsi.Root, randr.NotifyMaskScreenChange)
+			return nil
+		}
+	}
+	if err := xinerama.Init(s.xc); err == nil {
+		if reply, err := xinerama.IsActive(s.xc).Reply(); err == nil && reply.State != 0 {
+			s.xineramaAvailable = true
+		}
+	}
+	return nil
+}
+
+// Monitors returns the bounds, physical size, name, refresh rate and
+// primary flag of every output currently attached to the X server.
+func (s *screenImpl) Monitors() ([]screen.MonitorInfo, error) {
+	switch {
+	case s.randrAvailable:
+		return s.monitorsRandr()
+	case s.xineramaAvailable:
+		return s.monitorsXinerama()
+	}
+	return []screen.MonitorInfo{{
+		Bounds:  image.Rect(0, 0, int(s.xsi.WidthInPixels), int(s.xsi.HeightInPixels)),
+		Primary: true,
+	}}, nil
+}
+
+func (s *screenImpl) monitorsRandr() ([]screen.MonitorInfo, error) {
+	res, err := randr.GetScreenResourcesCurrent(s.xc, s.xsi.Root).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("x11driver: randr.GetScreenResourcesCurrent: %v", err)
+	}
+
+	primary, err := randr.GetOutputPrimary(s.xc, s.xsi.Root).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("x11driver: randr.GetOutputPrimary: %v", err)
+	}
+
+	var monitors []screen.MonitorInfo
+	for _, output := range res.Outputs {
+		oinfo, err := randr.GetOutputInfo(s.xc, output, res.ConfigTimestamp).Reply()
+		if err != nil || oinfo.Connection != randr.ConnectionConnected || oinfo.Crtc == 0 {
+			continue
+		}
+		cinfo, err := randr.GetCrtcInfo(s.xc, oinfo.Crtc, res.ConfigTimestamp).Reply()
+		if err != nil {
+			continue
+		}
+
+		var refresh float64
+		for _, mode := range res.Modes {
+			if mode.Id == uint32(cinfo.Mode) {
+				refresh = modeRefreshRate(mode)
+				break
+			}
+		}
+
+		monitors = append(monitors, screen.MonitorInfo{
+			Name: string(oinfo.Name),
+			Bounds: image.Rect(
+				int(cinfo.X), int(cinfo.Y),
+				int(cinfo.X)+int(cinfo.Width), int(cinfo.Y)+int(cinfo.Height),
+			),
+			PhysicalSizeMM: image.Pt(int(oinfo.MmWidth), int(oinfo.MmHeight)),
+			RefreshRate:    refresh,
+			Primary:        output == primary.Output,
+		})
+	}
+	return monitors, nil
+}
+
+// modeRefreshRate computes a mode's vertical refresh rate in Hz, per the
+// RandR protocol's dotClock / (hTotal * vTotal) formula.
+func modeRefreshRate(mode randr.ModeInfo) float64 {
+	if mode.Htotal == 0 || mode.Vtotal == 0 {
+		return 0
+	}
+	return float64(mode.DotClock) / (float64(mode.Htotal) * float64(mode.Vtotal))
+}
+
+func (s *screenImpl) monitorsXinerama() ([]screen.MonitorInfo, error) {
+	reply, err := xinerama.QueryScreens(s.xc).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("x11driver: xinerama.QueryScreens: %v", err)
+	}
+	monitors := make([]screen.MonitorInfo, len(reply.ScreenInfo))
+	for i, si := range reply.ScreenInfo {
+		monitors[i] = screen.MonitorInfo{
+			Bounds:  image.Rect(int(si.XOrg), int(si.YOrg), int(si.XOrg)+int(si.Width), int(si.YOrg)+int(si.Height)),
+			Primary: i == 0,
+		}
+	}
+	return monitors, nil
+}
+
+// handleScreenChange responds to a RandR hotplug/resolution-change
+// notification by delivering a screen.MonitorChangedEvent to every open
+// window, so that apps can react to DPI or layout changes.
+func (s *screenImpl) handleScreenChange(ev randr.ScreenChangeNotifyEvent) {
+	monitors, err := s.Monitors()
+	if err != nil {
+		s.logf("x11driver: Monitors: %v", nil, err)
+		return
+	}
+
+	s.mu.Lock()
+	windows := make([]*windowImpl, 0, len(s.windows))
+	for _, w := range s.windows {
+		windows = append(windows, w)
+	}
+	s.mu.Unlock()
+
+	for _, w := range windows {
+		w.Send(screen.MonitorChangedEvent{Monitors: monitors})
+	}
+}
@@ -5,16 +5,19 @@
 package x11driver
 
 import (
+	"context"
 	"fmt"
 	"image"
-	"log"
+	"os"
 	"sync"
 
 	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/randr"
 	"github.com/BurntSushi/xgb/render"
 	"github.com/BurntSushi/xgb/shm"
 	"github.com/BurntSushi/xgb/xproto"
 
+	"golang.org/x/exp/event"
 	"golang.org/x/exp/shiny/driver/internal/pump"
 	"golang.org/x/exp/shiny/screen"
 )
@@ -26,19 +29,62 @@ import (
 type completion struct {
 	sender screen.Sender
 	event  screen.UploadedEvent
+
+	// endSpan ends the event.Span that was started when the upload was
+	// issued, if any. It is called once handleCompletion receives the
+	// matching shm.CompletionEvent, since that's the only point at which
+	// the upload's true latency is known: the SHM completion is delivered
+	// asynchronously, well after the PutImage-equivalent request returns.
+	endSpan func()
 }
 
 type screenImpl struct {
 	xc  *xgb.Conn
 	xsi *xproto.ScreenInfo
 
+	// ctx is used only to look up an *event.Exporter (see newScreenImpl and
+	// the event package), so that x11driver's diagnostics can be routed
+	// through the same event pipeline as the rest of an instrumented
+	// program instead of going straight to the log package.
+	ctx context.Context
+
 	atomWMDeleteWindow xproto.Atom
 	atomWMProtocols    xproto.Atom
 	atomWMTakeFocus    xproto.Atom
 
+	atomNetWMName            xproto.Atom
+	atomNetWMPid             xproto.Atom
+	atomNetWMState           xproto.Atom
+	atomNetWMStateFullscreen xproto.Atom
+	atomUTF8String           xproto.Atom
+
 	pictformat24 render.Pictformat
 	pictformat32 render.Pictformat
 
+	// randrAvailable and xineramaAvailable record which (if either) of the
+	// two multi-monitor extensions the X server supports; randr is tried
+	// first and is preferred when both are present. Probed once in
+	// newScreenImpl, so they can be read without s.mu.
+	randrAvailable    bool
+	xineramaAvailable bool
+
+	// shapeAvailable is whether the X server supports the XSHAPE extension,
+	// used to give windows a non-rectangular SetShape region. It is probed
+	// once in newScreenImpl and never changes afterwards, so it can be read
+	// without s.mu.
+	shapeAvailable bool
+
+	// shmAvailable is whether the X server and connection support the
+	// MIT-SHM extension well enough to back screen.Buffers with shared
+	// memory pixmaps. It is probed once in newScreenImpl and never
+	// changes afterwards, so it can be read without s.mu.
+	//
+	// It is false for remote or forwarded displays (e.g. ssh -X) and for
+	// servers built without the SHM extension, in which case NewBuffer
+	// falls back to a fallbackBufferImpl that keeps its pixels in Go memory and
+	// uploads them via plain xproto.PutImage requests.
+	shmAvailable bool
+
 	// window32 and its related X11 resources is an unmapped window so that we
 	// have a depth-32 window to create depth-32 pixmaps from, i.e. pixmaps
 	// with an alpha channel. The root window isn't guaranteed to be depth-32.
@@ -51,8 +97,12 @@ type screenImpl struct {
 	windows map[xproto.Window]*windowImpl
 }
 
-func newScreenImpl(xc *xgb.Conn) (*screenImpl, error) {
+func newScreenImpl(ctx context.Context, xc *xgb.Conn) (*screenImpl, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	s := &screenImpl{
+		ctx:     ctx,
 		xc:      xc,
 		xsi:     xproto.Setup(xc).DefaultScreen(xc),
 		buffers: map[shm.Seg]*bufferImpl{},
@@ -68,15 +118,57 @@ func newScreenImpl(xc *xgb.Conn) (*screenImpl, error) {
 	if err := s.initWindow32(); err != nil {
 		return nil, err
 	}
+	s.shmAvailable = s.probeSHM()
+	s.shapeAvailable = s.probeShape()
+	if err := s.initMonitors(); err != nil {
+		return nil, err
+	}
 	go s.run()
 	return s, nil
 }
 
+// probeSHM reports whether the MIT-SHM extension is usable on this
+// connection. It is not enough for the extension to merely be present in
+// the X server's extension list, or for shm.QueryVersion to get a reply:
+// both happily succeed over a remote or forwarded display (e.g. ssh -X)
+// where the server-side SHM calls subsequently fail because the client and
+// server do not share any memory. So after QueryVersion, probeSHM attaches
+// a small shared memory segment with a checked request, which the server
+// rejects when it cannot reach the client's memory.
+//
+// shm.Init must be called before any shm request, including QueryVersion:
+// xgb panics on a request for an extension that was never registered via
+// its Init function.
+func (s *screenImpl) probeSHM() bool {
+	if err := shm.Init(s.xc); err != nil {
+		return false
+	}
+	if reply, err := shm.QueryVersion(s.xc).Reply(); err != nil || reply == nil {
+		return false
+	}
+	xs, err := shm.NewSegId(s.xc)
+	if err != nil {
+		return false
+	}
+	shmid, addr, err := shmOpen(4)
+	if err != nil {
+		return false
+	}
+	defer shmClose(addr)
+	if err := shm.AttachChecked(s.xc, xs, uint32(shmid), false).Check(); err != nil {
+		return false
+	}
+	shm.Detach(s.xc, xs)
+	return true
+}
+
 func (s *screenImpl) run() {
 	for {
 		ev, err := s.xc.WaitForEvent()
 		if err != nil {
-			log.Printf("x11driver: xproto.WaitForEvent: %v", err)
+			s.logf("x11driver: xproto.WaitForEvent: %v", []event.Label{
+				event.String("x11.event_type", "WaitForEvent"),
+			}, err)
 			continue
 		}
 
@@ -87,6 +179,9 @@ func (s *screenImpl) run() {
 		case shm.CompletionEvent:
 			s.handleCompletion(ev)
 			continue
+		case randr.ScreenChangeNotifyEvent:
+			s.handleScreenChange(ev)
+			continue
 		case xproto.ClientMessageEvent:
 			xw = ev.Window
 		case xproto.ConfigureNotifyEvent:
@@ -120,14 +215,23 @@ func (s *screenImpl) run() {
 		s.mu.Unlock()
 
 		if w == nil {
-			log.Printf("x11driver: no window found for event %T", ev)
+			s.logf("x11driver: no window found for event %T", []event.Label{
+				event.String("x11.event_type", fmt.Sprintf("%T", ev)),
+				event.Int64("x11.window", int64(xw)),
+			}, ev)
 			continue
 		}
+
+		_, end := event.Span(s.ctx).WithAll(
+			event.String("x11.event_type", fmt.Sprintf("%T", ev)),
+			event.Int64("x11.window", int64(xw)),
+		).Start("x11driver.dispatch")
 		if destroy {
 			close(w.xevents)
 		} else {
 			w.xevents <- ev
 		}
+		end()
 	}
 }
 
@@ -146,10 +250,15 @@ func (s *screenImpl) handleCompletion(ev shm.CompletionEvent) {
 	s.mu.Unlock()
 
 	if !ok {
-		log.Printf("x11driver: no matching upload for a SHM completion event")
+		s.logf("x11driver: no matching upload for a SHM completion event", []event.Label{
+			event.Int64("shm.sequence", int64(ev.Sequence)),
+		})
 		return
 	}
 	completion.event.Buffer.(*bufferImpl).postUpload()
+	if completion.endSpan != nil {
+		completion.endSpan()
+	}
 	if completion.sender != nil {
 		// Call Send in a separate goroutine, so that this event-handling
 		// goroutine doesn't block.
@@ -157,14 +266,80 @@ func (s *screenImpl) handleCompletion(ev shm.CompletionEvent) {
 	}
 }
 
+// registerUpload records that an upload identified by seq is in flight, so
+// that the matching shm.CompletionEvent in handleCompletion can locate its
+// sender, event and (if tracing is enabled) the span to end. screenImpl.upload
+// (below) calls this immediately after issuing the shm.PutImage
+// request that will eventually generate seq's completion.
+func (s *screenImpl) registerUpload(seq uint16, sender screen.Sender, ev screen.UploadedEvent, endSpan func()) {
+	s.mu.Lock()
+	s.uploads[seq] = completion{sender: sender, event: ev, endSpan: endSpan}
+	s.mu.Unlock()
+}
+
+// startUploadSpan starts an event.Span covering a buffer upload to a window
+// or texture, identified by the target drawable and the size of the
+// uploaded region (sr, not the whole buffer). The
+// returned func must be called exactly once, when the upload is known to
+// have completed; for SHM uploads that's from handleCompletion, since the
+// upload is asynchronous, while the fallback path (see buffer_fallback.go)
+// can end it as soon as its PutImage requests have been issued.
+func (s *screenImpl) startUploadSpan(xd xproto.Drawable, size image.Point) func() {
+	_, end := event.Span(s.ctx).WithAll(
+		event.Int64("x11.drawable", int64(xd)),
+		event.Int64("x11.upload_width", int64(size.X)),
+		event.Int64("x11.upload_height", int64(size.Y)),
+	).Start("x11driver.Upload")
+	return end
+}
+
+// upload is the common implementation behind windowImpl.Upload and
+// textureImpl.Upload. Buffers that implement bufferUploader (currently,
+// only the non-SHM fallback buffer) know how to upload themselves and do
+// so synchronously. A *bufferImpl, the SHM-backed screen.Buffer, instead
+// issues a shm.PutImage request and registers the resulting sequence
+// number: the upload only finishes, and its span only ends, when
+// handleCompletion later receives the matching shm.CompletionEvent.
+func (s *screenImpl) upload(xd xproto.Drawable, xg xproto.Gcontext, src screen.Buffer, dp image.Point, sr image.Rectangle, sender screen.Sender) {
+	uploadedEvent := screen.UploadedEvent{Buffer: src, Sender: sender}
+
+	if b, ok := src.(bufferUploader); ok {
+		b.upload(xd, xg, s.xsi.RootDepth, dp, sr, sender, uploadedEvent)
+		return
+	}
+
+	b, ok := src.(*bufferImpl)
+	if !ok {
+		return
+	}
+
+	endSpan := s.startUploadSpan(xd, sr.Size())
+	cookie := shm.PutImage(
+		s.xc, xd, xg,
+		uint16(b.size.X), uint16(b.size.Y),
+		uint16(sr.Min.X), uint16(sr.Min.Y), uint16(sr.Dx()), uint16(sr.Dy()),
+		int16(dp.X), int16(dp.Y),
+		s.xsi.RootDepth, xproto.ImageFormatZPixmap,
+		1, b.xs, 0,
+	)
+	s.registerUpload(cookie.Sequence, sender, uploadedEvent, endSpan)
+}
+
 const (
 	maxShmSide = 0x00007fff // 32,767 pixels.
 	maxShmSize = 0x10000000 // 268,435,456 bytes.
 )
 
 func (s *screenImpl) NewBuffer(size image.Point) (retBuf screen.Buffer, retErr error) {
-	// TODO: detect if the X11 server or connection cannot support SHM pixmaps,
-	// and fall back to regular pixmaps.
+	_, end := event.Span(s.ctx).WithAll(
+		event.Int64("x11.buffer_width", int64(size.X)),
+		event.Int64("x11.buffer_height", int64(size.Y)),
+	).Start("x11driver.NewBuffer")
+	defer end()
+
+	if !s.shmAvailable {
+		return newFallbackBuffer(s, size)
+	}
 
 	w, h := int64(size.X), int64(size.Y)
 	if w < 0 || maxShmSide < w || h < 0 || maxShmSide < h || maxShmSize < 4*w*h {
@@ -214,6 +389,12 @@ func (s *screenImpl) NewBuffer(size image.Point) (retBuf screen.Buffer, retErr e
 }
 
 func (s *screenImpl) NewTexture(size image.Point) (screen.Texture, error) {
+	_, end := event.Span(s.ctx).WithAll(
+		event.Int64("x11.texture_width", int64(size.X)),
+		event.Int64("x11.texture_height", int64(size.Y)),
+	).Start("x11driver.NewTexture")
+	defer end()
+
 	w, h := int64(size.X), int64(size.Y)
 	if w < 0 || maxShmSide < w || h < 0 || maxShmSide < h || maxShmSize < 4*w*h {
 		return nil, fmt.Errorf("x11driver: invalid texture size %v", size)
@@ -241,8 +422,19 @@ func (s *screenImpl) NewTexture(size image.Point) (screen.Texture, error) {
 }
 
 func (s *screenImpl) NewWindow(opts *screen.NewWindowOptions) (screen.Window, error) {
-	// TODO: look at opts.
-	const width, height = 1024, 768
+	_, end := event.Span(s.ctx).Start("x11driver.NewWindow")
+	defer end()
+
+	const defaultWidth, defaultHeight = 1024, 768
+	width, height := defaultWidth, defaultHeight
+	if opts != nil {
+		if opts.Width > 0 {
+			width = opts.Width
+		}
+		if opts.Height > 0 {
+			height = opts.Height
+		}
+	}
 
 	xw, err := xproto.NewWindowId(s.xc)
 	if err != nil {
@@ -281,7 +473,7 @@ func (s *screenImpl) NewWindow(opts *screen.NewWindowOptions) (screen.Window, er
 	s.mu.Unlock()
 
 	xproto.CreateWindow(s.xc, s.xsi.RootDepth, xw, s.xsi.Root,
-		0, 0, width, height, 0,
+		0, 0, uint16(width), uint16(height), 0,
 		xproto.WindowClassInputOutput, s.xsi.RootVisual,
 		xproto.CwEventMask,
 		[]uint32{0 |
@@ -298,11 +490,110 @@ func (s *screenImpl) NewWindow(opts *screen.NewWindowOptions) (screen.Window, er
 	s.setProperty(xw, s.atomWMProtocols, s.atomWMDeleteWindow, s.atomWMTakeFocus)
 	xproto.CreateGC(s.xc, xg, xproto.Drawable(xw), 0, nil)
 	render.CreatePicture(s.xc, xp, xproto.Drawable(xw), pictformat, 0, nil)
+
+	title := ""
+	if opts != nil {
+		title = opts.Title
+	}
+	s.setWMName(xw, title)
+	s.setWMClass(xw)
+	s.setWMNormalHints(xw, width, height)
+	s.setWMPid(xw)
+
+	// Seed the size that SetShape resets to, until a ConfigureNotifyEvent
+	// reports the window's actual size.
+	w.width, w.height = width, height
+
 	xproto.MapWindow(s.xc, xw)
 
+	if opts != nil && opts.Fullscreen {
+		s.setFullscreen(xw)
+	}
+
 	return w, nil
 }
 
+// setWMName sets _NET_WM_NAME (and, for window managers that don't look at
+// EWMH properties, the ICCCM WM_NAME) to title, encoded as UTF-8.
+func (s *screenImpl) setWMName(xw xproto.Window, title string) {
+	b := []byte(title)
+	xproto.ChangeProperty(s.xc, xproto.PropModeReplace, xw,
+		s.atomNetWMName, s.atomUTF8String, 8, uint32(len(b)), b)
+	xproto.ChangeProperty(s.xc, xproto.PropModeReplace, xw,
+		xproto.AtomWmName, xproto.AtomString, 8, uint32(len(b)), b)
+}
+
+// setWMClass sets WM_CLASS to a generic instance and class name, so that
+// window managers and task bars can group and theme shiny windows even
+// though the application itself doesn't get a say in the name.
+func (s *screenImpl) setWMClass(xw xproto.Window) {
+	const instance, class = "shiny", "Shiny"
+	b := make([]byte, 0, len(instance)+len(class)+2)
+	b = append(b, instance...)
+	b = append(b, 0)
+	b = append(b, class...)
+	b = append(b, 0)
+	xproto.ChangeProperty(s.xc, xproto.PropModeReplace, xw,
+		xproto.AtomWmClass, xproto.AtomString, 8, uint32(len(b)), b)
+}
+
+// setWMNormalHints sets WM_NORMAL_HINTS so that window managers don't let
+// the user resize the window beyond the size shiny created it with; shiny's
+// backing buffers are a fixed size and don't yet support being resized.
+func (s *screenImpl) setWMNormalHints(xw xproto.Window, width, height int) {
+	const pMinSize, pMaxSize = 1 << 4, 1 << 5
+	hints := make([]uint32, 18)
+	hints[0] = pMinSize | pMaxSize
+	hints[5], hints[6] = uint32(width), uint32(height) // min_width, min_height
+	hints[7], hints[8] = uint32(width), uint32(height) // max_width, max_height
+	xproto.ChangePropertyChecked(s.xc, xproto.PropModeReplace, xw,
+		xproto.AtomWmNormalHints, xproto.AtomWmSizeHints, 32, uint32(len(hints)), uint32sToBytes(hints))
+}
+
+// setWMPid sets _NET_WM_PID so that window managers and task bars can
+// associate the window with this process.
+func (s *screenImpl) setWMPid(xw xproto.Window) {
+	pid := uint32(os.Getpid())
+	xproto.ChangeProperty(s.xc, xproto.PropModeReplace, xw,
+		s.atomNetWMPid, xproto.AtomCardinal, 32, 1, uint32sToBytes([]uint32{pid}))
+}
+
+// setFullscreen asks the window manager to put xw into the
+// _NET_WM_STATE_FULLSCREEN state, per the EWMH "Source Indication in
+// Requests" convention: clients don't set _NET_WM_STATE directly on a
+// mapped window, they send a ClientMessage to the root window and let the
+// window manager apply it.
+func (s *screenImpl) setFullscreen(xw xproto.Window) {
+	const netWMStateAdd = 1
+	ev := xproto.ClientMessageEvent{
+		Format: 32,
+		Window: xw,
+		Type:   s.atomNetWMState,
+		Data: xproto.ClientMessageDataUnionData32New([]uint32{
+			netWMStateAdd,
+			uint32(s.atomNetWMStateFullscreen),
+			0,
+			1, // Source indication: normal application.
+			0,
+		}),
+	}
+	const eventMask = xproto.EventMaskSubstructureRedirect | xproto.EventMaskSubstructureNotify
+	xproto.SendEvent(s.xc, false, s.xsi.Root, eventMask, string(ev.Bytes()))
+}
+
+// uint32sToBytes packs vs into a little-endian byte slice, as required by
+// xproto.ChangeProperty for format-32 properties.
+func uint32sToBytes(vs []uint32) []byte {
+	b := make([]byte, 4*len(vs))
+	for i, v := range vs {
+		b[4*i+0] = uint8(v >> 0)
+		b[4*i+1] = uint8(v >> 8)
+		b[4*i+2] = uint8(v >> 16)
+		b[4*i+3] = uint8(v >> 24)
+	}
+	return b
+}
+
 func (s *screenImpl) initAtoms() (err error) {
 	s.atomWMDeleteWindow, err = s.internAtom("WM_DELETE_WINDOW")
 	if err != nil {
@@ -316,6 +607,26 @@ func (s *screenImpl) initAtoms() (err error) {
 	if err != nil {
 		return err
 	}
+	s.atomNetWMName, err = s.internAtom("_NET_WM_NAME")
+	if err != nil {
+		return err
+	}
+	s.atomNetWMPid, err = s.internAtom("_NET_WM_PID")
+	if err != nil {
+		return err
+	}
+	s.atomNetWMState, err = s.internAtom("_NET_WM_STATE")
+	if err != nil {
+		return err
+	}
+	s.atomNetWMStateFullscreen, err = s.internAtom("_NET_WM_STATE_FULLSCREEN")
+	if err != nil {
+		return err
+	}
+	s.atomUTF8String, err = s.internAtom("UTF8_STRING")
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -427,4 +738,4 @@ func (s *screenImpl) setProperty(xw xproto.Window, prop xproto.Atom, values ...x
 		b[4*i+3] = uint8(v >> 24)
 	}
 	xproto.ChangeProperty(s.xc, xproto.PropModeReplace, xw, prop, xproto.AtomAtom, 32, uint32(len(values)), b)
-}
\ No newline at end of file
+}
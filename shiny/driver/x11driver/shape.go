@@ -0,0 +1,72 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11driver
+
+import (
+	"image"
+
+	"github.com/BurntSushi/xgb/shape"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// probeShape reports whether the X server supports the XSHAPE extension,
+// which SetShape needs to give a window a non-rectangular region.
+//
+// shape.Init must be called before any shape request, including
+// QueryVersion: xgb panics on a request for an extension that was never
+// registered via its Init function.
+func (s *screenImpl) probeShape() bool {
+	if err := shape.Init(s.xc); err != nil {
+		return false
+	}
+	reply, err := shape.QueryVersion(s.xc).Reply()
+	return err == nil && reply != nil
+}
+
+// SetShape restricts w's visible and clickable region to the union of
+// rects, in window-local coordinates, using the XSHAPE extension. A nil or
+// empty rects resets the window back to its default, fully rectangular
+// shape.
+//
+// SetShape is a no-op, rather than an error, when the X server doesn't
+// support XSHAPE: most window managers will simply continue to show the
+// window as a plain rectangle.
+func (w *windowImpl) SetShape(rects []image.Rectangle) {
+	if !w.s.shapeAvailable {
+		return
+	}
+
+	if len(rects) == 0 {
+		// Reset to the window's full rectangle, i.e. its current size as
+		// last reported by an xproto.ConfigureNotifyEvent, not an empty
+		// (0x0) one: an all-zero rectangle would make the window invisible
+		// and unclickable instead of restoring its normal, fully
+		// rectangular shape.
+		full := xproto.Rectangle{Width: uint16(w.width), Height: uint16(w.height)}
+		shape.Rectangles(w.s.xc, shape.SoSet, shape.SkBounding, 0, w.xw, 0, 0, []xproto.Rectangle{full})
+		shape.Rectangles(w.s.xc, shape.SoSet, shape.SkClip, 0, w.xw, 0, 0, []xproto.Rectangle{full})
+		return
+	}
+
+	xrects := make([]xproto.Rectangle, len(rects))
+	for i, r := range rects {
+		xrects[i] = xproto.Rectangle{
+			X:      int16(r.Min.X),
+			Y:      int16(r.Min.Y),
+			Width:  uint16(r.Dx()),
+			Height: uint16(r.Dy()),
+		}
+	}
+
+	// The first rectangle establishes the region (SoSet); any remaining
+	// rectangles are unioned into it (SoUnion), so that the final shape is
+	// the union of all of rects.
+	for _, kind := range []shape.Kind{shape.SkBounding, shape.SkClip} {
+		shape.Rectangles(w.s.xc, shape.SoSet, kind, 0, w.xw, 0, 0, xrects[:1])
+		if len(xrects) > 1 {
+			shape.Rectangles(w.s.xc, shape.SoUnion, kind, 0, w.xw, 0, 0, xrects[1:])
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11driver
+
+import (
+	"image"
+
+	"github.com/BurntSushi/xgb/render"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// textureImpl is the x11driver implementation of screen.Texture. Unlike a
+// buffer, a texture isn't backed by shared memory: its pixels live in a
+// server-side Pixmap, reached only through Upload.
+type textureImpl struct {
+	s    *screenImpl
+	size image.Point
+	xm   xproto.Pixmap
+	xp   render.Picture
+}
+
+func (t *textureImpl) Release() {
+	render.FreePicture(t.s.xc, t.xp)
+	xproto.FreePixmap(t.s.xc, t.xm)
+}
+
+func (t *textureImpl) Size() image.Point       { return t.size }
+func (t *textureImpl) Bounds() image.Rectangle { return image.Rectangle{Max: t.size} }
+
+// Upload draws src's pixels, starting at sr.Min, onto the texture at dp.
+func (t *textureImpl) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle, sender screen.Sender) {
+	t.s.upload(xproto.Drawable(t.xm), t.s.gcontext32, src, dp, sr, sender)
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11driver
+
+import (
+	"image"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/render"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"golang.org/x/exp/shiny/driver/internal/pump"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// windowImpl is the x11driver implementation of screen.Window.
+type windowImpl struct {
+	s  *screenImpl
+	xw xproto.Window
+	xg xproto.Gcontext
+	xp render.Picture
+
+	pump    pump.Pump
+	xevents chan xgb.Event
+
+	width, height int
+}
+
+// run pumps the xproto events that screenImpl.run routes to w.xevents,
+// updating w's cached width and height from ConfigureNotifyEvents (so that
+// SetShape can restore the window's full, current size) and forwarding
+// every event, ConfigureNotifyEvents included, to w.pump for NextEvent to
+// return. It exits once
+// w.xevents is closed, which screenImpl.run does on a DestroyNotifyEvent.
+func (w *windowImpl) run() {
+	for ev := range w.xevents {
+		if configureNotify, ok := ev.(xproto.ConfigureNotifyEvent); ok {
+			w.width = int(configureNotify.Width)
+			w.height = int(configureNotify.Height)
+		}
+		w.pump.Send(ev)
+	}
+}
+
+// Release closes the window.
+func (w *windowImpl) Release() {
+	xproto.DestroyWindow(w.s.xc, w.xw)
+}
+
+// Send adds an event to the end of the window's event queue.
+func (w *windowImpl) Send(event interface{}) {
+	w.pump.Send(event)
+}
+
+// NextEvent returns the next event in the window's event queue, blocking
+// until one is available.
+func (w *windowImpl) NextEvent() interface{} {
+	return w.pump.NextEvent()
+}
+
+// Upload draws src's pixels, starting at sr.Min, onto the window at dp. See
+// textureImpl.Upload for the texture equivalent.
+func (w *windowImpl) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle, sender screen.Sender) {
+	w.s.upload(xproto.Drawable(w.xw), w.xg, src, dp, sr, sender)
+}
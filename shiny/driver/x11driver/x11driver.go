@@ -0,0 +1,37 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/BurntSushi/xgb"
+
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// NewScreen opens a connection to the X server named by the DISPLAY
+// environment variable and returns a screen.Screen for it.
+func NewScreen() (screen.Screen, error) {
+	return NewScreenWithExporter(nil)
+}
+
+// NewScreenWithExporter is like NewScreen, but every diagnostic message and
+// trace span x11driver emits while using the returned screen.Screen is
+// delivered to e instead of the standard log package.
+// Passing a nil e is equivalent to calling NewScreen.
+func NewScreenWithExporter(e *event.Exporter) (screen.Screen, error) {
+	xc, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("x11driver: xgb.NewConn: %v", err)
+	}
+	ctx := context.Background()
+	if e != nil {
+		ctx = event.WithExporter(ctx, e)
+	}
+	return newScreenImpl(ctx, xc)
+}
@@ -0,0 +1,137 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package screen provides interfaces for portable two-dimensional graphics
+// and input events.
+package screen
+
+import (
+	"image"
+)
+
+// Screen creates Buffers, Textures and Windows.
+type Screen interface {
+	// NewBuffer returns a new Buffer for this screen.
+	NewBuffer(size image.Point) (Buffer, error)
+
+	// NewTexture returns a new Texture for this screen.
+	NewTexture(size image.Point) (Texture, error)
+
+	// NewWindow returns a new Window for this screen.
+	NewWindow(opts *NewWindowOptions) (Window, error)
+
+	// Monitors returns the bounds, physical size, name, refresh rate and
+	// primary flag of every display output currently attached to this
+	// screen.
+	Monitors() ([]MonitorInfo, error)
+}
+
+// MonitorInfo describes a single physical display output attached to a
+// Screen.
+type MonitorInfo struct {
+	// Name is the output's name, as reported by the platform. It may be
+	// empty if the platform or driver doesn't have one to report.
+	Name string
+
+	// Bounds is the monitor's position and size within the Screen's
+	// overall virtual coordinate space.
+	Bounds image.Rectangle
+
+	// PhysicalSizeMM is the monitor's physical size in millimeters, or the
+	// zero Point if unknown.
+	PhysicalSizeMM image.Point
+
+	// RefreshRate is the monitor's vertical refresh rate in Hz, or zero if
+	// unknown.
+	RefreshRate float64
+
+	// Primary is whether this is the platform's designated primary
+	// monitor.
+	Primary bool
+}
+
+// MonitorChangedEvent is sent to a Window's event queue when the Screen's
+// monitor configuration changes, e.g. a monitor is hotplugged, unplugged,
+// or its resolution changes.
+type MonitorChangedEvent struct {
+	Monitors []MonitorInfo
+}
+
+// NewWindowOptions are optional arguments to NewWindow.
+type NewWindowOptions struct {
+	// Width and Height specify the dimensions of the new window. If either
+	// are zero, a driver-dependent default will be used.
+	Width, Height int
+
+	// Title specifies the window title.
+	Title string
+
+	// Fullscreen specifies that the window should be created fullscreen,
+	// covering its initial monitor entirely.
+	Fullscreen bool
+}
+
+// Buffer is an in-memory pixel buffer. Its memory might be on the GPU, but
+// at least one of the CPU and GPU should be able to read and write it.
+type Buffer interface {
+	// Release releases the Buffer's resources.
+	Release()
+
+	// Size returns the size of the Buffer's image.
+	Size() image.Point
+
+	// Bounds returns the bounds of the Buffer's image. It is the image's
+	// bounds relative to the top-left corner, i.e. it always has a zero
+	// Min field.
+	Bounds() image.Rectangle
+
+	// RGBA returns the pixel buffer as an *image.RGBA.
+	RGBA() *image.RGBA
+}
+
+// Texture is a pixel buffer, but not one that is directly accessible as a
+// []byte. Conceptually, it could live on a GPU, in another process or even
+// be across a network, rather than in memory.
+type Texture interface {
+	// Release releases the Texture's resources.
+	Release()
+
+	// Size returns the size of the Texture's image.
+	Size() image.Point
+
+	// Bounds returns the bounds of the Texture's image.
+	Bounds() image.Rectangle
+
+	// Upload draws the sub-image defined by src and sr to the destination
+	// (this texture), such that sr.Min in src-space aligns with dp in
+	// dst-space.
+	Upload(dp image.Point, src Buffer, sr image.Rectangle, sender Sender)
+}
+
+// Window is a top-level, on-screen window.
+type Window interface {
+	// Release closes the window.
+	Release()
+
+	// Upload draws the sub-image defined by src and sr to the destination
+	// (this window), such that sr.Min in src-space aligns with dp in
+	// dst-space.
+	Upload(dp image.Point, src Buffer, sr image.Rectangle, sender Sender)
+
+	// Send adds an event to the end of the window's event queue, to be
+	// returned by a future NextEvent call.
+	Send(event interface{})
+}
+
+// Sender is something that events can be Sent to.
+type Sender interface {
+	Send(event interface{})
+}
+
+// UploadedEvent is sent by Window.Upload and Texture.Upload when the
+// previously requested upload has completed.
+type UploadedEvent struct {
+	Buffer Buffer
+	Sender Sender
+}